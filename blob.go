@@ -0,0 +1,239 @@
+package datastore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// blobCodecName marks an Item's encoded bytes as a blobRef rather than a regular codec-encoded value.
+const blobCodecName = "blob"
+
+// blobsDirName is the directory directly under Datastore.path that content-addressed blob bytes are stored in.
+const blobsDirName = "blobs"
+
+// blobRef is what actually gets stored in a Document's data map for a key set via SetBlob: a pointer to the real
+// bytes living in Datastore.path/blobs, not the bytes themselves.
+type blobRef struct {
+	Digest string
+	Size   int64
+}
+
+func (d *Datastore) blobsDir() string {
+	return filepath.Join(d.path, blobsDirName)
+}
+
+// writeBlob streams r to a temporary file while hashing it, then moves it into the content-addressed blobs
+// directory under its SHA-256 digest. If a blob with that digest already exists, the temp file is discarded
+// instead of overwriting it - identical content is only ever stored once.
+func (d *Datastore) writeBlob(r io.Reader) (blobRef, error) {
+	if err := os.MkdirAll(d.blobsDir(), 0700); err != nil {
+		return blobRef{}, err
+	}
+
+	tmp, err := os.CreateTemp(d.blobsDir(), "tmp-*")
+	if err != nil {
+		return blobRef{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), r)
+	if err != nil {
+		tmp.Close()
+		return blobRef{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return blobRef{}, err
+	}
+
+	ref := blobRef{
+		Digest: hex.EncodeToString(hasher.Sum(nil)),
+		Size:   size,
+	}
+
+	finalPath := filepath.Join(d.blobsDir(), ref.Digest)
+	if _, err := os.Stat(finalPath); os.IsNotExist(err) {
+		if err := os.Rename(tmp.Name(), finalPath); err != nil {
+			return blobRef{}, err
+		}
+	}
+
+	return ref, nil
+}
+
+// retainBlobRef increments the in-memory reference count for digest. It's called whenever a Document key is set
+// (or re-set) to point at digest.
+func (d *Datastore) retainBlobRef(digest string) {
+	d.blobRefsMu.Lock()
+	defer d.blobRefsMu.Unlock()
+
+	if d.blobRefs == nil {
+		d.blobRefs = map[string]int{}
+	}
+	d.blobRefs[digest]++
+}
+
+// releaseBlobRef decrements the in-memory reference count for digest. It's the inverse of retainBlobRef.
+func (d *Datastore) releaseBlobRef(digest string) {
+	d.blobRefsMu.Lock()
+	defer d.blobRefsMu.Unlock()
+
+	if d.blobRefs[digest] > 0 {
+		d.blobRefs[digest]--
+	}
+}
+
+// releaseBlobValue decrements the reference count for the blob referenced by an item's raw stored bytes, if it
+// is in fact a blob reference. It's a no-op for anything else, so callers can call it unconditionally when
+// overwriting or deleting a Document key without checking what kind of value it held.
+func (d *Datastore) releaseBlobValue(b []byte) {
+	name, payload, err := decodeItem(b)
+	if err != nil || name != blobCodecName {
+		return
+	}
+
+	var ref blobRef
+	if err := GobCodec.Decode(payload, &ref); err != nil {
+		return
+	}
+
+	d.releaseBlobRef(ref.Digest)
+}
+
+// rebuildBlobRefs recomputes blob reference counts from scratch by walking every Document currently loaded into
+// the Datastore. It's called once after Open finishes loading the on-disk snapshot and replaying the WAL, since
+// refcounts themselves aren't persisted.
+func (d *Datastore) rebuildBlobRefs() {
+	d.blobRefsMu.Lock()
+	defer d.blobRefsMu.Unlock()
+
+	d.blobRefs = map[string]int{}
+
+	var walkCollection func(*Collection)
+	walkCollection = func(c *Collection) {
+		for _, document := range c.documents.Data {
+			for _, v := range document.data.Data {
+				name, payload, err := decodeItem(v)
+				if err != nil || name != blobCodecName {
+					continue
+				}
+
+				var ref blobRef
+				if err := GobCodec.Decode(payload, &ref); err != nil {
+					continue
+				}
+
+				d.blobRefs[ref.Digest]++
+			}
+		}
+
+		for _, sub := range c.collections.Data {
+			walkCollection(sub)
+		}
+	}
+
+	for _, collection := range d.collections.Data {
+		walkCollection(collection)
+	}
+
+	// Blobs already on disk that no Document references get a count of 0, so GC can reclaim blobs orphaned before
+	// this Open as well as ones released since.
+	entries, _ := os.ReadDir(d.blobsDir())
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), "tmp-") {
+			continue
+		}
+		if _, ok := d.blobRefs[entry.Name()]; !ok {
+			d.blobRefs[entry.Name()] = 0
+		}
+	}
+}
+
+// GC removes every blob in the Datastore's blobs directory that no Document currently references, and returns how
+// many were removed. Deleting a Document key that held a blob only decrements its reference count; GC is what
+// actually reclaims the disk space once the count reaches zero.
+func (d *Datastore) GC() (int, error) {
+	d.blobRefsMu.Lock()
+	defer d.blobRefsMu.Unlock()
+
+	removed := 0
+	for digest, refs := range d.blobRefs {
+		if refs > 0 {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(d.blobsDir(), digest)); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+
+		delete(d.blobRefs, digest)
+		removed++
+	}
+
+	return removed, nil
+}
+
+// SetBlob streams r into content-addressed storage and points key at the result, without ever holding the full
+// value in memory. Use Item.Reader to stream it back out.
+func (d *Document) SetBlob(key string, r io.Reader) error {
+	if d.ds == nil {
+		return fmt.Errorf("datastore: SetBlob requires a Document created within a Datastore")
+	}
+
+	ref, err := d.ds.writeBlob(r)
+	if err != nil {
+		return fmt.Errorf("could not store blob in document: %w", err)
+	}
+
+	// Retain the ref immediately, before the blob is pointed to by any Document key, so a GC racing with this call
+	// can never observe a digest whose bytes writeBlob just placed on disk with a refcount of 0 (which happens
+	// whenever identical content was referenced once before and has since been fully released) and delete them out
+	// from under us.
+	d.ds.retainBlobRef(ref.Digest)
+
+	payload, err := GobCodec.Encode(ref)
+	if err != nil {
+		d.ds.releaseBlobRef(ref.Digest)
+		return fmt.Errorf("could not store blob in document: %w", err)
+	}
+	b := encodeItem(blobCodecName, payload)
+
+	if err := d.setRaw(key, b); err != nil {
+		d.ds.releaseBlobRef(ref.Digest)
+		return err
+	}
+
+	return nil
+}
+
+// Reader opens the blob referenced by i for streaming, without loading it fully into memory. It only works for
+// Items set via Document.SetBlob; anything else returns ErrNotBlob.
+func (i Item) Reader() (io.ReadCloser, error) {
+	if len(i.Value) == 0 {
+		return nil, ErrEmptyItem
+	}
+
+	name, payload, err := decodeItem(i.Value)
+	if err != nil {
+		return nil, err
+	}
+	if name != blobCodecName {
+		return nil, ErrNotBlob
+	}
+
+	if i.ds == nil {
+		return nil, fmt.Errorf("datastore: item is not associated with a Datastore")
+	}
+
+	var ref blobRef
+	if err := GobCodec.Decode(payload, &ref); err != nil {
+		return nil, err
+	}
+
+	return os.Open(filepath.Join(i.ds.blobsDir(), ref.Digest))
+}
@@ -0,0 +1,168 @@
+package datastore
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestSetBlobAndGC(t *testing.T) {
+	dir := t.TempDir()
+
+	ds, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	doc := ds.Collection("files").Document("report")
+	if err := doc.SetBlob("body", bytes.NewReader([]byte("hello world"))); err != nil {
+		t.Fatalf("SetBlob: %v", err)
+	}
+
+	rc, err := doc.Get("body").Reader()
+	if err != nil {
+		t.Fatalf("Reader: %v", err)
+	}
+	got, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if string(got) != "hello world" {
+		t.Fatalf("got %q", got)
+	}
+
+	// Referenced, so GC must not collect it.
+	if n, err := ds.GC(); err != nil || n != 0 {
+		t.Fatalf("GC() = %d, %v; want 0, nil", n, err)
+	}
+
+	if err := doc.Delete("body"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	// No longer referenced, so GC must collect exactly the one blob.
+	n, err := ds.GC()
+	if err != nil {
+		t.Fatalf("GC: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("GC() removed %d blobs, want 1", n)
+	}
+}
+
+func TestSetOverwritingABlobReleasesIt(t *testing.T) {
+	dir := t.TempDir()
+
+	ds, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	doc := ds.Collection("files").Document("report")
+	if err := doc.SetBlob("body", bytes.NewReader([]byte("original"))); err != nil {
+		t.Fatalf("SetBlob: %v", err)
+	}
+
+	// Overwriting the key through the plain Set path, not SetBlob or Delete, must still release the old blob ref.
+	if err := doc.Set("body", "plain value"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	n, err := ds.GC()
+	if err != nil {
+		t.Fatalf("GC: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("GC() removed %d blobs, want 1 (the overwritten original)", n)
+	}
+}
+
+func TestCollectionSetReplacingDocumentReleasesBlobs(t *testing.T) {
+	dir := t.TempDir()
+
+	ds, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	collection := ds.Collection("files")
+	doc := collection.Document("report")
+	if err := doc.SetBlob("body", bytes.NewReader([]byte("original"))); err != nil {
+		t.Fatalf("SetBlob: %v", err)
+	}
+
+	fresh := NewDocument("report")
+	if err := fresh.Set("body", "replaced"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := collection.Set("report", fresh); err != nil {
+		t.Fatalf("Collection.Set: %v", err)
+	}
+
+	n, err := ds.GC()
+	if err != nil {
+		t.Fatalf("GC: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("GC() removed %d blobs, want 1 (the replaced document's original blob)", n)
+	}
+}
+
+func TestCollectionSetReplacingDocumentUpdatesIndex(t *testing.T) {
+	dir := t.TempDir()
+
+	ds, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	RegisterExtractor("blob-test-body", func(item Item) []byte {
+		return item.Value
+	})
+
+	collection := ds.Collection("files")
+	doc := collection.Document("report")
+	if err := doc.Set("body", "original"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	idx, err := collection.CreateIndex("by-body", "blob-test-body")
+	if err != nil {
+		t.Fatalf("CreateIndex: %v", err)
+	}
+
+	fresh := NewDocument("report")
+	if err := fresh.Set("other-key", "replaced"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := collection.Set("report", fresh); err != nil {
+		t.Fatalf("Collection.Set: %v", err)
+	}
+
+	items, err := collection.Query().Using(idx).Run()
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want 1 (the replaced document's only key, re-indexed)", len(items))
+	}
+	if items[0].Key != "other-key" {
+		t.Fatalf("got item for key %q, want %q", items[0].Key, "other-key")
+	}
+	var value string
+	if err := items[0].Decode(&value); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if value != "replaced" {
+		t.Fatalf("got value %q, want %q", value, "replaced")
+	}
+}
+
+// ensure SetBlob requires a Datastore-backed Document.
+func TestSetBlobRequiresDatastore(t *testing.T) {
+	doc := NewDocument("standalone")
+	if err := doc.SetBlob("body", bytes.NewReader(nil)); err == nil {
+		t.Fatalf("expected an error setting a blob on a standalone Document")
+	}
+}
@@ -0,0 +1,140 @@
+package datastore
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Codec encodes and decodes the values stored under a Document key. Built-in codecs are GobCodec, JSONCodec, and
+// BSONCodec; callers can implement their own and register it with RegisterCodec to make it resolvable on read.
+type Codec interface {
+	Encode(v any) ([]byte, error)
+	Decode(b []byte, v any) error
+	Name() string
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Encode(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Decode(b []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(b)).Decode(v)
+}
+
+func (gobCodec) Name() string {
+	return "gob"
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Encode(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Decode(b []byte, v any) error {
+	return json.Unmarshal(b, v)
+}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+type bsonCodec struct{}
+
+func (bsonCodec) Encode(v any) ([]byte, error) {
+	return bson.Marshal(v)
+}
+
+func (bsonCodec) Decode(b []byte, v any) error {
+	return bson.Unmarshal(b, v)
+}
+
+func (bsonCodec) Name() string {
+	return "bson"
+}
+
+// GobCodec, JSONCodec, and BSONCodec are the built-in Codec implementations. GobCodec is used when no other codec
+// is configured, matching the module's historical behavior.
+var (
+	GobCodec  Codec = gobCodec{}
+	JSONCodec Codec = jsonCodec{}
+	BSONCodec Codec = bsonCodec{}
+)
+
+var (
+	codecRegistryMu sync.RWMutex
+	codecRegistry   = map[string]Codec{}
+)
+
+// RegisterCodec makes codec resolvable by name when decoding an Item whose header was written with it. The three
+// built-in codecs are registered automatically; call this for any custom Codec passed to WithCodec.
+func RegisterCodec(codec Codec) {
+	codecRegistryMu.Lock()
+	defer codecRegistryMu.Unlock()
+
+	codecRegistry[codec.Name()] = codec
+}
+
+func codecByName(name string) (Codec, bool) {
+	codecRegistryMu.RLock()
+	defer codecRegistryMu.RUnlock()
+
+	codec, ok := codecRegistry[name]
+	return codec, ok
+}
+
+func init() {
+	RegisterCodec(GobCodec)
+	RegisterCodec(JSONCodec)
+	RegisterCodec(BSONCodec)
+}
+
+// EncodeValue encodes v with codec and wraps the result in the same small header Document.Set uses, so the bytes
+// it returns can be written directly into a Document's storage (as the remote package's client does, since it
+// can't call the unexported encodeItem itself) and later read back with Item.Decode.
+func EncodeValue(codec Codec, v any) ([]byte, error) {
+	payload, err := codec.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeItem(codec.Name(), payload), nil
+}
+
+// encodeItem prefixes payload, the codec-encoded bytes of a value, with a small header recording the codec's name.
+// This lets Item.Decode dispatch to the right codec per-value rather than assuming a single codec for the whole
+// Datastore, which in turn lets Open read documents written by a previous process using a different codec.
+func encodeItem(codecName string, payload []byte) []byte {
+	b := make([]byte, 0, 1+len(codecName)+len(payload))
+	b = append(b, byte(len(codecName)))
+	b = append(b, codecName...)
+	b = append(b, payload...)
+
+	return b
+}
+
+// decodeItem splits a header-prefixed value (see encodeItem) back into its codec name and payload.
+func decodeItem(b []byte) (codecName string, payload []byte, err error) {
+	if len(b) < 1 {
+		return "", nil, ErrEmptyItem
+	}
+
+	n := int(b[0])
+	if len(b) < 1+n {
+		return "", nil, fmt.Errorf("datastore: corrupt item header")
+	}
+
+	return string(b[1 : 1+n]), b[1+n:], nil
+}
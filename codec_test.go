@@ -0,0 +1,95 @@
+package datastore
+
+import "testing"
+
+type codecTestValue struct {
+	Name string
+	Age  int
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	codecs := []Codec{GobCodec, JSONCodec, BSONCodec}
+
+	for _, codec := range codecs {
+		t.Run(codec.Name(), func(t *testing.T) {
+			dir := t.TempDir()
+
+			ds, err := Open(dir, WithCodec(codec))
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+
+			want := codecTestValue{Name: "ada", Age: 36}
+			doc := ds.Collection("people").Document("ada")
+			if err := doc.Set("profile", want); err != nil {
+				t.Fatalf("Set: %v", err)
+			}
+
+			var got codecTestValue
+			if err := doc.Get("profile").Decode(&got); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if got != want {
+				t.Fatalf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestCodecPerValueHeaderSurvivesCodecSwitch(t *testing.T) {
+	dir := t.TempDir()
+
+	ds, err := Open(dir, WithCodec(JSONCodec))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	doc := ds.Collection("people").Document("grace")
+	if err := doc.Set("profile", codecTestValue{Name: "grace", Age: 85}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := ds.Checkpoint(); err != nil {
+		t.Fatalf("Checkpoint: %v", err)
+	}
+	if err := ds.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Reopen with a different default codec. The value written under JSONCodec must still decode correctly because
+	// each value's own header records which codec produced it, independent of the Datastore's current default.
+	reopened, err := Open(dir, WithCodec(BSONCodec))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	var got codecTestValue
+	if err := reopened.Collection("people").Document("grace").Get("profile").Decode(&got); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got.Name != "grace" || got.Age != 85 {
+		t.Fatalf("got %+v", got)
+	}
+}
+
+func TestWithCodecOverridesPerDocument(t *testing.T) {
+	dir := t.TempDir()
+
+	ds, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	collection := ds.Collection("people")
+	doc := collection.Document("turing").WithCodec(JSONCodec)
+	if err := doc.Set("profile", codecTestValue{Name: "turing", Age: 41}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	var got codecTestValue
+	if err := doc.Get("profile").Decode(&got); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got.Name != "turing" {
+		t.Fatalf("got %+v", got)
+	}
+}
@@ -1,6 +1,7 @@
 package datastore
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/eolso/threadsafe"
@@ -9,8 +10,15 @@ import (
 // Collection effectively represents a folder. A Collection may contain uniquely named Document(s) or more Collection(s).
 type Collection struct {
 	name        string
+	path        []string
 	documents   *threadsafe.Map[string, *Document]
 	collections *threadsafe.Map[string, *Collection]
+
+	// ds is the owning Datastore, if any. It's used to journal mutations to the WAL. A Collection created outside
+	// of a Datastore (there is no exported constructor for one) will always have a nil ds.
+	ds *Datastore
+
+	indexes *threadsafe.Map[string, *Index]
 }
 
 type CollectionReader interface {
@@ -20,7 +28,7 @@ type CollectionReader interface {
 
 type CollectionWriter interface {
 	Set(key string, document *Document) error
-	Delete(key string)
+	Delete(key string) error
 }
 
 type CollectionReadWriter interface {
@@ -28,11 +36,14 @@ type CollectionReadWriter interface {
 	CollectionWriter
 }
 
-func newCollection(name string) *Collection {
+func newCollection(name string, path []string, ds *Datastore) *Collection {
 	return &Collection{
 		name:        name,
+		path:        path,
 		documents:   threadsafe.NewMap[string, *Document](),
 		collections: threadsafe.NewMap[string, *Collection](),
+		ds:          ds,
+		indexes:     threadsafe.NewMap[string, *Index](),
 	}
 }
 
@@ -41,7 +52,18 @@ func (c *Collection) Get(key string) (*Document, bool) {
 }
 
 func (c *Collection) GetAll() []*Document {
-	return c.documents.Values()
+	documents, _ := c.GetAllCtx(context.Background())
+	return documents
+}
+
+// GetAllCtx is the context-aware equivalent of GetAll. ctx is checked before the Collection's Documents are
+// gathered; a cancellation returns ctx.Err() wrapped instead of a partial result.
+func (c *Collection) GetAllCtx(ctx context.Context) ([]*Document, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("datastore: collection %q: %w", c.name, err)
+	}
+
+	return c.documents.Values(), nil
 }
 
 func (c *Collection) Set(key string, document *Document) error {
@@ -49,24 +71,91 @@ func (c *Collection) Set(key string, document *Document) error {
 		return fmt.Errorf("cannot insert nil document into collection")
 	}
 
+	// If key already names a Document, it's about to be replaced wholesale rather than merged into: release any
+	// blobs it held and drop its entries from any index on c first, exactly as Delete would, so neither leaks a
+	// reference to a Document that's no longer reachable through c.
+	if old, ok := c.documents.Get(key); ok {
+		for k, v := range old.data.Data {
+			if c.ds != nil {
+				c.ds.releaseBlobValue(v)
+				if err := c.ds.appendWAL(walOpDelete, c.path, key, k, nil); err != nil {
+					return err
+				}
+			}
+			c.notifyIndexes(key, k, Item{}, true)
+		}
+	}
+
+	document.collection = c
+	document.ds = c.ds
+	document.collectionPath = c.path
 	c.documents.Set(key, document)
 
+	// Journal every key currently held by the document being attached so a WAL replay can reconstruct it without
+	// needing a distinct "attach document" record type, and feed the same keys through any indexes on c.
+	for k, v := range document.data.Data {
+		if c.ds != nil {
+			if err := c.ds.appendWAL(walOpSet, c.path, key, k, v); err != nil {
+				return err
+			}
+		}
+		c.notifyIndexes(key, k, Item{Key: k, Value: v, ds: c.ds}, false)
+	}
+
 	return nil
 }
 
-func (c *Collection) Delete(key string) {
+// Delete removes the Document named key from c, journaling the delete of every key it held exactly as Set
+// journals a write - a failed journal write is returned rather than swallowed, since a caller that doesn't learn
+// about it would believe the delete survived a crash when it never reached the WAL.
+func (c *Collection) Delete(key string) error {
+	if document, ok := c.documents.Get(key); ok {
+		for k, v := range document.data.Data {
+			if c.ds != nil {
+				c.ds.releaseBlobValue(v)
+				if err := c.ds.appendWAL(walOpDelete, c.path, key, k, nil); err != nil {
+					return err
+				}
+			}
+			c.notifyIndexes(key, k, Item{}, true)
+		}
+	}
+
 	c.documents.Delete(key)
+
+	return nil
+}
+
+// notifyIndexes updates every index on c after documentName's key has been set or deleted. It's a no-op if c has
+// no indexes.
+func (c *Collection) notifyIndexes(documentName, key string, item Item, removed bool) {
+	for _, index := range c.indexes.Data {
+		index.update(documentName, key, item, removed)
+	}
 }
 
 func (c *Collection) DeleteCollection(key string) {
 	c.collections.Delete(key)
 }
 
+// Collections returns the names of every Collection nested directly under c.
+func (c *Collection) Collections() []string {
+	names := make([]string, 0, c.collections.Len())
+	for name := range c.collections.Data {
+		names = append(names, name)
+	}
+
+	return names
+}
+
 // Document is a helper function that returns an existing document if it exists, and creates it if it doesn't.
 func (c *Collection) Document(name string) *Document {
 	document, ok := c.documents.Get(name)
 	if !ok {
 		document = NewDocument(name)
+		document.ds = c.ds
+		document.collectionPath = c.path
+		document.collection = c
 		c.documents.Set(name, document)
 	}
 
@@ -76,7 +165,11 @@ func (c *Collection) Document(name string) *Document {
 func (c *Collection) Collection(name string) *Collection {
 	collection, ok := c.collections.Get(name)
 	if !ok {
-		collection = newCollection(name)
+		path := make([]string, len(c.path), len(c.path)+1)
+		copy(path, c.path)
+		path = append(path, name)
+
+		collection = newCollection(name, path, c.ds)
 		c.collections.Set(name, collection)
 	}
 
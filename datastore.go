@@ -1,58 +1,172 @@
 package datastore
 
 import (
+	"context"
 	"encoding/base64"
+	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/eolso/threadsafe"
 )
 
+// defaultSyncInterval is used when WithSyncPolicy(SyncInterval) is set without an explicit WithSyncInterval.
+const defaultSyncInterval = time.Second
+
+// walFileName is the name of the write-ahead log file kept directly under a Datastore's path.
+const walFileName = "wal.log"
+
 // Datastore is the top level mechanism in charge of reading and writing the documents on disk. Datastore(s) contain
 // Collection(s) only, and cannot directly hold a Document.
 type Datastore struct {
 	path        string
 	collections *threadsafe.Map[string, *Collection]
 	lock        sync.Mutex
+
+	wal          *wal
+	syncPolicy   SyncPolicy
+	syncInterval time.Duration
+
+	// defaultCodec is used to encode/decode Document values that don't set their own via Document.WithCodec.
+	defaultCodec Codec
+
+	// blobRefs tracks how many Document keys currently point at each blob digest. It's rebuilt from scratch on
+	// Open (see rebuildBlobRefs) rather than persisted.
+	blobRefs   map[string]int
+	blobRefsMu sync.Mutex
+
+	// indexManifestMu guards reads and rewrites of the index manifest file (see persistIndex), which records which
+	// Collection.CreateIndex calls have been made so Open can rebuild them via rebuildIndexes.
+	indexManifestMu sync.Mutex
+}
+
+// DatastoreOption configures optional behavior on a Datastore. See WithSyncPolicy and WithSyncInterval.
+type DatastoreOption func(*Datastore)
+
+// WithSyncPolicy sets the fsync policy used by the Datastore's WAL. The default is SyncAlways.
+func WithSyncPolicy(policy SyncPolicy) DatastoreOption {
+	return func(d *Datastore) {
+		d.syncPolicy = policy
+	}
+}
+
+// WithSyncInterval sets the interval used by the SyncInterval sync policy. It has no effect with any other policy.
+func WithSyncInterval(interval time.Duration) DatastoreOption {
+	return func(d *Datastore) {
+		d.syncInterval = interval
+	}
+}
+
+// WithCodec sets the default Codec used to encode and decode Document values in the Datastore. Individual
+// Documents can still override it via Document.WithCodec. The default is GobCodec.
+func WithCodec(codec Codec) DatastoreOption {
+	return func(d *Datastore) {
+		d.defaultCodec = codec
+	}
 }
 
 // NewDatastore creates and returns a *Datastore with path. In most cases, Open should be called instead as this
-// constructor will not attempt to read any Datastore from disk.
-func NewDatastore(path string) *Datastore {
-	return &Datastore{
-		path:        filepath.Clean(path),
-		collections: threadsafe.NewMap[string, *Collection](),
+// constructor will not attempt to read any Datastore from disk, nor will it open a WAL.
+func NewDatastore(path string, opts ...DatastoreOption) *Datastore {
+	d := &Datastore{
+		path:         filepath.Clean(path),
+		collections:  threadsafe.NewMap[string, *Collection](),
+		syncPolicy:   SyncAlways,
+		syncInterval: defaultSyncInterval,
+		defaultCodec: GobCodec,
+	}
+
+	for _, opt := range opts {
+		opt(d)
 	}
+
+	return d
 }
 
 // Collection returns the *Collection named name. If it doesn't exist a new one will be created and returned.
 func (d *Datastore) Collection(name string) *Collection {
 	collection, ok := d.collections.Get(name)
 	if !ok {
-		collection = newCollection(name)
+		collection = newCollection(name, []string{name}, d)
 		d.collections.Set(name, collection)
 	}
 
 	return collection
 }
 
-// Open reads path for an existing Datastore and returns it. If one does not exist, it will be created and returned.
-// If the program does not have permissions to read/write to the path specified, this will return an error.
-func Open(path string) (*Datastore, error) {
-	datastore := NewDatastore(path)
-	//baseDepth := strings.Count(path, string(os.PathSeparator))
+// DeleteCollection removes the top level Collection named name from the Datastore.
+func (d *Datastore) DeleteCollection(name string) {
+	d.collections.Delete(name)
+}
+
+// Collections returns the names of every top level Collection in the Datastore.
+func (d *Datastore) Collections() []string {
+	names := make([]string, 0, d.collections.Len())
+	for name := range d.collections.Data {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// appendWAL journals a single mutation. It's a no-op if the Datastore was constructed via NewDatastore directly
+// instead of Open, since there is no WAL to journal to in that case.
+func (d *Datastore) appendWAL(op walOp, collectionPath []string, document, key string, value []byte) error {
+	if d.wal == nil {
+		return nil
+	}
+
+	// Copy the path since callers may continue to mutate the slice backing array via append.
+	path := make([]string, len(collectionPath))
+	copy(path, collectionPath)
+
+	return d.wal.append(walRecord{
+		Op:             op,
+		CollectionPath: path,
+		Document:       document,
+		Key:            key,
+		Value:          value,
+	})
+}
+
+// Open reads path for an existing Datastore and returns it. It's equivalent to OpenCtx with context.Background().
+func Open(path string, opts ...DatastoreOption) (*Datastore, error) {
+	return OpenCtx(context.Background(), path, opts...)
+}
+
+// OpenCtx reads path for an existing Datastore and returns it. If one does not exist, it will be created and
+// returned. If the program does not have permissions to read/write to the path specified, this will return an
+// error.
+//
+// Any WAL records left over from a previous session that crashed before a Checkpoint are replayed on top of the
+// on-disk snapshot. ctx is checked between collections and documents as the snapshot is walked and replayed; a
+// cancellation aborts the load and returns ctx.Err() wrapped with however far Open got.
+func OpenCtx(ctx context.Context, path string, opts ...DatastoreOption) (*Datastore, error) {
+	datastore := NewDatastore(path, opts...)
+
+	// If a checkpoint swap was interrupted mid-flight, the previous snapshot will still be sitting at path+".bak".
+	// Restore it before doing anything else.
+	backupPath := path + ".bak"
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if _, berr := os.Stat(backupPath); berr == nil {
+			if err := os.Rename(backupPath, path); err != nil {
+				return datastore, err
+			}
+		}
+	}
 
 	// If the directory does not exist, create it and return an empty Datastore
 	stat, err := os.Stat(path)
 	if err != nil && os.IsNotExist(err) {
-		return datastore, os.MkdirAll(path, 0700)
+		if err := os.MkdirAll(path, 0700); err != nil {
+			return datastore, err
+		}
 	} else if err != nil {
 		return datastore, err
-	}
-
-	// Datastore must be a directory
-	if !stat.IsDir() {
+	} else if !stat.IsDir() {
+		// Datastore must be a directory
 		return datastore, ErrInvalidPath
 	}
 
@@ -65,6 +179,10 @@ func Open(path string) (*Datastore, error) {
 	// Define a recursive function for traversing a collection
 	var traverseCollection func(*Collection, *Collection, string) error
 	traverseCollection = func(collection *Collection, previousCollection *Collection, path string) error {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("datastore: open: %w", err)
+		}
+
 		// Check if the current path is a Document
 		pathEntries, err := os.ReadDir(path)
 		if err != nil {
@@ -76,7 +194,7 @@ func Open(path string) (*Datastore, error) {
 		for _, pathEntry := range pathEntries {
 			if pathEntry.IsDir() {
 				isCollection = true
-			} else {
+			} else if pathEntry.Name() != walFileName {
 				isDocument = true
 			}
 		}
@@ -125,11 +243,32 @@ func Open(path string) (*Datastore, error) {
 	}
 
 	for _, entry := range entries {
-		// A Datastore may _only_ contain collections, so no files should exist at this level.
+		if err := ctx.Err(); err != nil {
+			return datastore, fmt.Errorf("datastore: open: %w", err)
+		}
+
 		if !entry.IsDir() {
+			// The WAL file lives alongside the top level collections; skip it here, it's opened below.
+			if entry.Name() == walFileName {
+				continue
+			}
+
+			// The index manifest (and a leftover temp file from an interrupted persistIndex write) lives alongside
+			// the top level collections too; it's read below by rebuildIndexes, not a Collection.
+			if entry.Name() == indexManifestFileName || entry.Name() == indexManifestFileName+".tmp" {
+				continue
+			}
+
+			// A Datastore may _only_ contain collections otherwise, so no other files should exist at this level.
 			return datastore, ErrInvalidPath
 		}
 
+		// The blobs directory also lives alongside the top level collections; it's content-addressed storage, not
+		// a Collection, so skip it here.
+		if entry.Name() == blobsDirName {
+			continue
+		}
+
 		// Create the Collection in the Datastore
 		collection := datastore.Collection(entry.Name())
 
@@ -139,19 +278,95 @@ func Open(path string) (*Datastore, error) {
 		}
 	}
 
-	return datastore, err
+	// Open the WAL and replay anything left over from a session that ended without a Checkpoint.
+	w, err := openWAL(filepath.Join(path, walFileName), datastore.syncPolicy, datastore.syncInterval)
+	if err != nil {
+		return datastore, err
+	}
+	datastore.wal = w
+
+	err = w.replay(func(record walRecord) error {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("datastore: open: wal replay: %w", err)
+		}
+
+		if len(record.CollectionPath) == 0 {
+			return ErrInvalidPath
+		}
+
+		collection := datastore.Collection(record.CollectionPath[0])
+		for _, name := range record.CollectionPath[1:] {
+			collection = collection.Collection(name)
+		}
+		document := collection.Document(record.Document)
+
+		switch record.Op {
+		case walOpSet:
+			document.RawSet(record.Key, record.Value)
+		case walOpDelete:
+			document.data.Delete(record.Key)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return datastore, err
+	}
+
+	datastore.rebuildBlobRefs()
+
+	if err := datastore.rebuildIndexes(); err != nil {
+		return datastore, fmt.Errorf("datastore: open: %w", err)
+	}
+
+	return datastore, nil
 }
 
-// Close flushes the current Datastore and writes to disk. TODO it should always be writing to disk.
-func (d *Datastore) Close() error {
+// Checkpoint flushes the in-memory Datastore to disk. It's equivalent to CheckpointCtx with context.Background().
+func (d *Datastore) Checkpoint() error {
+	return d.CheckpointCtx(context.Background())
+}
+
+// CheckpointCtx flushes the in-memory Datastore to disk as a new snapshot using an atomic rename, then truncates
+// the WAL. A crash at any point leaves either the previous snapshot or the new one intact, never a partial write.
+// ctx is checked between collections as the snapshot is written; a cancellation aborts the flush and returns
+// ctx.Err() wrapped, leaving the previous on-disk snapshot untouched.
+func (d *Datastore) CheckpointCtx(ctx context.Context) error {
 	d.lock.Lock()
 	defer d.lock.Unlock()
 
+	if err := d.flush(ctx); err != nil {
+		return err
+	}
+
+	if d.wal != nil {
+		return d.wal.reset()
+	}
+
+	return nil
+}
+
+// flush writes the full in-memory Datastore to a temporary directory and atomically swaps it in for d.path.
+func (d *Datastore) flush(ctx context.Context) error {
+	tmpPath := d.path + ".tmp"
+	backupPath := d.path + ".bak"
+
+	if err := os.RemoveAll(tmpPath); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(tmpPath, 0700); err != nil {
+		return err
+	}
+
 	// Recursive function to traverse collections with collections
 	var flushCollection func(name string, c *Collection) error
 	flushCollection = func(name string, c *Collection) error {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("datastore: checkpoint: %w", err)
+		}
+
 		// Create the Collection directory
-		collectionPath := filepath.Join(d.path, name)
+		collectionPath := filepath.Join(tmpPath, name)
 		if err := os.MkdirAll(collectionPath, 0700); err != nil {
 			return err
 		}
@@ -186,5 +401,57 @@ func (d *Datastore) Close() error {
 		}
 	}
 
+	// Carry the blobs directory over into the new snapshot. Blob content is already on disk and content-addressed,
+	// so there's nothing to rewrite - a directory rename is enough to bring it along for the swap below.
+	if _, err := os.Stat(d.blobsDir()); err == nil {
+		if err := os.Rename(d.blobsDir(), filepath.Join(tmpPath, blobsDirName)); err != nil {
+			return err
+		}
+	}
+
+	// Carry the index manifest over into the new snapshot the same way, so the indexes created via CreateIndex are
+	// still rebuilt the next time this Datastore is opened.
+	manifestPath := filepath.Join(d.path, indexManifestFileName)
+	if _, err := os.Stat(manifestPath); err == nil {
+		if err := os.Rename(manifestPath, filepath.Join(tmpPath, indexManifestFileName)); err != nil {
+			return err
+		}
+	}
+
+	// Swap the new snapshot in. The previous snapshot is kept at backupPath until the swap fully succeeds, so a
+	// crash between the two renames still leaves a complete, loadable Datastore on disk (see Open).
+	if err := os.RemoveAll(backupPath); err != nil {
+		return err
+	}
+	if _, err := os.Stat(d.path); err == nil {
+		if err := os.Rename(d.path, backupPath); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(tmpPath, d.path); err != nil {
+		_ = os.Rename(backupPath, d.path)
+		return err
+	}
+
+	return os.RemoveAll(backupPath)
+}
+
+// Close checkpoints the current Datastore to disk and closes its WAL. It's equivalent to CloseCtx with
+// context.Background().
+func (d *Datastore) Close() error {
+	return d.CloseCtx(context.Background())
+}
+
+// CloseCtx checkpoints the current Datastore to disk and closes its WAL, aborting if ctx is cancelled first. See
+// CheckpointCtx.
+func (d *Datastore) CloseCtx(ctx context.Context) error {
+	if err := d.CheckpointCtx(ctx); err != nil {
+		return err
+	}
+
+	if d.wal != nil {
+		return d.wal.close()
+	}
+
 	return nil
 }
@@ -0,0 +1,95 @@
+package datastore
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDocumentGetAllCtxCancelled(t *testing.T) {
+	dir := t.TempDir()
+
+	ds, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	doc := ds.Collection("people").Document("grace")
+	if err := doc.Set("age", 85); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := doc.GetAllCtx(ctx); err == nil {
+		t.Fatalf("expected GetAllCtx to return an error for an already-cancelled context")
+	}
+}
+
+func TestCollectionGetAllCtxCancelled(t *testing.T) {
+	dir := t.TempDir()
+
+	ds, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	collection := ds.Collection("people")
+	if err := collection.Document("grace").Set("age", 85); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := collection.GetAllCtx(ctx); err == nil {
+		t.Fatalf("expected GetAllCtx to return an error for an already-cancelled context")
+	}
+}
+
+func TestOpenCtxCancelled(t *testing.T) {
+	dir := t.TempDir()
+
+	// Seed the directory with a Datastore first so OpenCtx has something to walk.
+	ds, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := ds.Collection("people").Document("grace").Set("age", 85); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := ds.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := OpenCtx(ctx, dir); err == nil {
+		t.Fatalf("expected OpenCtx to return an error for an already-cancelled context")
+	}
+}
+
+func TestDeleteErrorIsNotSwallowed(t *testing.T) {
+	dir := t.TempDir()
+
+	ds, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	doc := ds.Collection("people").Document("henry")
+	if err := doc.Set("age", 60); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	// Close the WAL file out from under the Datastore to force appendWAL to fail, the same way a disk-full or I/O
+	// error would. Delete must surface that failure rather than reporting success.
+	if err := ds.wal.file.Close(); err != nil {
+		t.Fatalf("close wal file: %v", err)
+	}
+
+	if err := doc.Delete("age"); err == nil {
+		t.Fatalf("expected Delete to return an error when the WAL journal write fails")
+	}
+}
@@ -1,8 +1,7 @@
 package datastore
 
 import (
-	"bytes"
-	"encoding/gob"
+	"context"
 	"fmt"
 	"reflect"
 
@@ -12,11 +11,29 @@ import (
 type Item struct {
 	Key   string
 	Value []byte
+
+	// ds is the owning Datastore, if any. It's used by Reader to resolve a blob reference to the file holding its
+	// bytes.
+	ds *Datastore
 }
 
 type Document struct {
 	Name string
 	data *threadsafe.Map[string, []byte]
+
+	// ds and collectionPath are set when the Document is created via Collection.Document, and are used to journal
+	// mutations to the owning Datastore's WAL. A Document created directly via NewDocument has a nil ds and its
+	// mutations are never journaled.
+	ds             *Datastore
+	collectionPath []string
+
+	// collection is the Collection this Document was created in, if any. It's used to keep any of that
+	// Collection's indexes in sync with the Document's keys. A Document created directly via NewDocument has a
+	// nil collection and is never indexed.
+	collection *Collection
+
+	// codec overrides the owning Datastore's default codec for this Document. See WithCodec.
+	codec Codec
 }
 
 type DocumentReader interface {
@@ -26,7 +43,7 @@ type DocumentReader interface {
 
 type DocumentWriter interface {
 	Set(key string, value interface{}) error
-	Delete(key string)
+	Delete(key string) error
 }
 
 type DocumentReadWriter interface {
@@ -44,7 +61,17 @@ func (i Item) Decode(v interface{}) error {
 		return ErrEmptyItem
 	}
 
-	return gob.NewDecoder(bytes.NewBuffer(i.Value)).Decode(v)
+	codecName, payload, err := decodeItem(i.Value)
+	if err != nil {
+		return err
+	}
+
+	codec, ok := codecByName(codecName)
+	if !ok {
+		return fmt.Errorf("datastore: unregistered codec %q", codecName)
+	}
+
+	return codec.Decode(payload, v)
 }
 
 func NewDocument(name string) *Document {
@@ -60,33 +87,111 @@ func (d *Document) Get(key string) Item {
 		return Item{}
 	}
 
-	return Item{Key: key, Value: b}
+	return Item{Key: key, Value: b, ds: d.ds}
 }
 
 func (d *Document) GetAll() []Item {
-	items := make([]Item, d.data.Len())
+	items, _ := d.GetAllCtx(context.Background())
+	return items
+}
+
+// GetAllCtx is the context-aware equivalent of GetAll. ctx is checked before d's Items are gathered; a
+// cancellation returns ctx.Err() wrapped instead of a partial result.
+func (d *Document) GetAllCtx(ctx context.Context) ([]Item, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("datastore: document %q: %w", d.Name, err)
+	}
+
+	items := make([]Item, 0, d.data.Len())
 	for k, v := range d.data.Data {
-		items = append(items, Item{Key: k, Value: v})
+		items = append(items, Item{Key: k, Value: v, ds: d.ds})
 	}
 
-	return items
+	return items, nil
+}
+
+// WithCodec overrides the Codec used to encode and decode values set on d, regardless of the owning Datastore's
+// default. It returns d so it can be chained off of Collection.Document.
+func (d *Document) WithCodec(codec Codec) *Document {
+	d.codec = codec
+	return d
+}
+
+// effectiveCodec returns the Codec that should be used to encode a new value set on d: d.codec if one was configured via
+// WithCodec, otherwise the owning Datastore's default, falling back to GobCodec for a standalone Document.
+func (d *Document) effectiveCodec() Codec {
+	if d.codec != nil {
+		return d.codec
+	}
+
+	if d.ds != nil && d.ds.defaultCodec != nil {
+		return d.ds.defaultCodec
+	}
+
+	return GobCodec
 }
 
 func (d *Document) Set(key string, value interface{}) error {
-	var b bytes.Buffer
-	if err := gob.NewEncoder(&b).Encode(value); err != nil {
+	b, err := EncodeValue(d.effectiveCodec(), value)
+	if err != nil {
 		return fmt.Errorf("could not store value in document: %w", err)
 	}
 
-	d.data.Set(key, b.Bytes())
+	return d.setRaw(key, b)
+}
+
+// setRaw stores b, already-encoded bytes, under key, journaling the write and notifying any indexes on the owning
+// Collection exactly as Set does. It's the common path behind Set, SetBlob, and the remote server's write handler,
+// all of which arrive at the final encoded bytes differently but need to land them the same way.
+func (d *Document) setRaw(key string, b []byte) error {
+	if d.ds != nil {
+		if old, ok := d.data.Get(key); ok {
+			d.ds.releaseBlobValue(old)
+		}
+	}
+
+	d.data.Set(key, b)
+
+	if d.ds != nil {
+		if err := d.ds.appendWAL(walOpSet, d.collectionPath, d.Name, key, b); err != nil {
+			return fmt.Errorf("could not journal document write: %w", err)
+		}
+	}
+
+	if d.collection != nil {
+		d.collection.notifyIndexes(d.Name, key, Item{Key: key, Value: b, ds: d.ds}, false)
+	}
 
 	return nil
 }
 
+// RawSet sets key to the raw, already-encoded bytes b without journaling the write. It's used internally to
+// reconstruct a Document from an on-disk snapshot or WAL replay.
 func (d *Document) RawSet(key string, b []byte) {
 	d.data.Set(key, b)
 }
 
-func (d *Document) Delete(key string) {
+// Delete removes key from d, journaling the delete exactly as Set journals a write - a failed journal write is
+// returned rather than swallowed, since a caller that doesn't learn about it would believe the delete survived a
+// crash when it never reached the WAL.
+func (d *Document) Delete(key string) error {
+	if d.ds != nil {
+		if old, ok := d.data.Get(key); ok {
+			d.ds.releaseBlobValue(old)
+		}
+	}
+
 	d.data.Delete(key)
+
+	if d.ds != nil {
+		if err := d.ds.appendWAL(walOpDelete, d.collectionPath, d.Name, key, nil); err != nil {
+			return fmt.Errorf("could not journal document delete: %w", err)
+		}
+	}
+
+	if d.collection != nil {
+		d.collection.notifyIndexes(d.Name, key, Item{}, true)
+	}
+
+	return nil
 }
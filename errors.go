@@ -8,6 +8,7 @@ import (
 var ErrKeyNotFound = errors.New("datastore: key not found")
 var ErrEmptyItem = errors.New("datastore: Decode(empty item")
 var ErrInvalidPath = errors.New("datastore: path must be a directory")
+var ErrNotBlob = errors.New("datastore: item was not set via SetBlob")
 
 type ErrInvalidDecode struct {
 	Type reflect.Type
@@ -0,0 +1,474 @@
+package datastore
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	iradix "github.com/hashicorp/go-immutable-radix/v2"
+)
+
+// indexManifestFileName is the name of the file, kept directly under a Datastore's path alongside wal.log, that
+// records which indexes have been created so Open can rebuild them.
+const indexManifestFileName = "indexes.gob"
+
+// indexManifestEntry is one persisted record of a Collection.CreateIndex call: enough to call it again against the
+// same Collection once the extractor name it names has been registered with RegisterExtractor.
+type indexManifestEntry struct {
+	CollectionPath []string
+	Name           string
+	ExtractorName  string
+}
+
+// readIndexManifest reads the index manifest at d.path, returning nil if it doesn't exist yet.
+func (d *Datastore) readIndexManifest() ([]indexManifestEntry, error) {
+	b, err := os.ReadFile(filepath.Join(d.path, indexManifestFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var entries []indexManifestEntry
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// persistIndex records that a Collection.CreateIndex call for a given name and extractorName was made on the
+// Collection at path, overwriting any previous record for the same path and name, so Open can recreate it later.
+// The manifest is rewritten as a whole via a temp file and rename, the same atomic-swap pattern Checkpoint uses for
+// the full snapshot.
+func (d *Datastore) persistIndex(path []string, name, extractorName string) error {
+	d.indexManifestMu.Lock()
+	defer d.indexManifestMu.Unlock()
+
+	entries, err := d.readIndexManifest()
+	if err != nil {
+		return err
+	}
+
+	collectionPath := make([]string, len(path))
+	copy(collectionPath, path)
+
+	replaced := false
+	for i, entry := range entries {
+		if entry.Name == name && stringsEqual(entry.CollectionPath, collectionPath) {
+			entries[i].ExtractorName = extractorName
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		entries = append(entries, indexManifestEntry{CollectionPath: collectionPath, Name: name, ExtractorName: extractorName})
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(entries); err != nil {
+		return err
+	}
+
+	tmpPath := filepath.Join(d.path, indexManifestFileName+".tmp")
+	if err := os.WriteFile(tmpPath, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, filepath.Join(d.path, indexManifestFileName))
+}
+
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// rebuildIndexes recreates every index recorded in the Datastore's index manifest, navigating to (and creating,
+// exactly as a WAL replay does) each one's Collection by its persisted path. It's called once after Open finishes
+// loading the on-disk snapshot and replaying the WAL. An extractor that was never registered with RegisterExtractor
+// before Open was called is reported as an error rather than silently skipped, since a missing index is a
+// correctness gap a caller needs to know about.
+func (d *Datastore) rebuildIndexes() error {
+	entries, err := d.readIndexManifest()
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if len(entry.CollectionPath) == 0 {
+			return ErrInvalidPath
+		}
+
+		collection := d.Collection(entry.CollectionPath[0])
+		for _, name := range entry.CollectionPath[1:] {
+			collection = collection.Collection(name)
+		}
+
+		if _, ok := collection.Index(entry.Name); ok {
+			continue
+		}
+
+		if _, ok := extractorByName(entry.ExtractorName); !ok {
+			return fmt.Errorf("datastore: index %q: extractor %q was not registered with RegisterExtractor before Open", entry.Name, entry.ExtractorName)
+		}
+
+		if _, err := collection.CreateIndex(entry.Name, entry.ExtractorName); err != nil {
+			return fmt.Errorf("datastore: rebuilding index %q: %w", entry.Name, err)
+		}
+	}
+
+	return nil
+}
+
+var (
+	extractorRegistryMu sync.RWMutex
+	extractorRegistry   = map[string]func(Item) []byte{}
+)
+
+// RegisterExtractor makes extractor resolvable by name, so an index built from it can be recreated automatically
+// on Open rather than requiring a fresh CreateIndex call every time a Datastore is reopened. Register every
+// extractor a program uses before calling Open.
+func RegisterExtractor(name string, extractor func(Item) []byte) {
+	extractorRegistryMu.Lock()
+	defer extractorRegistryMu.Unlock()
+
+	extractorRegistry[name] = extractor
+}
+
+func extractorByName(name string) (func(Item) []byte, bool) {
+	extractorRegistryMu.RLock()
+	defer extractorRegistryMu.RUnlock()
+
+	extractor, ok := extractorRegistry[name]
+	return extractor, ok
+}
+
+// Index is a secondary, sorted index over the Item(s) in a Collection, keyed by the bytes an extractor function
+// derives from each one (a timestamp, a user ID, a tag - whatever the caller wants to look values up or range
+// scan by). It's created with Collection.CreateIndex and queried with Collection.Query.
+type Index struct {
+	name string
+	// extractorName is the name extractor was registered under with RegisterExtractor. It's persisted to the
+	// owning Datastore's index manifest so the index can be rebuilt automatically on Open.
+	extractorName string
+	extractor     func(Item) []byte
+	collection    *Collection
+
+	mu   sync.RWMutex
+	tree *iradix.Tree[[]indexRef]
+	// keys tracks the last index key each (document, key) pair was filed under, so update can find and remove the
+	// old entry when a value changes which extracted key it maps to.
+	keys map[string][]byte
+}
+
+// indexRef points back at the Document key an index entry was derived from.
+type indexRef struct {
+	Document string
+	Key      string
+}
+
+// CreateIndex builds a new secondary index named name over c's current Documents, keyed by whatever the extractor
+// registered under extractorName returns for each Item. The extractor returning nil excludes that Item from the
+// index. The index is kept up to date as Documents in c are set and deleted.
+//
+// extractorName is persisted to the owning Datastore's index manifest, so the index is rebuilt automatically the
+// next time the Datastore is opened with Open - as long as the same name is registered with RegisterExtractor
+// before that Open runs; Open returns an error if any persisted index names an extractor that isn't registered.
+// Calling CreateIndex again by hand also works, and is the way to repair an index if it's ever suspected to be out
+// of sync.
+func (c *Collection) CreateIndex(name string, extractorName string) (*Index, error) {
+	extractor, ok := extractorByName(extractorName)
+	if !ok {
+		return nil, fmt.Errorf("datastore: index %q: extractor %q was not registered with RegisterExtractor", name, extractorName)
+	}
+
+	if _, ok := c.indexes.Get(name); ok {
+		return nil, fmt.Errorf("datastore: index %q already exists on this collection", name)
+	}
+
+	index := &Index{
+		name:          name,
+		extractorName: extractorName,
+		extractor:     extractor,
+		collection:    c,
+		tree:          iradix.New[[]indexRef](),
+		keys:          map[string][]byte{},
+	}
+	index.rebuild()
+
+	c.indexes.Set(name, index)
+
+	if c.ds != nil {
+		if err := c.ds.persistIndex(c.path, name, extractorName); err != nil {
+			return nil, fmt.Errorf("datastore: index %q: %w", name, err)
+		}
+	}
+
+	return index, nil
+}
+
+// Index returns the named index previously created on c with CreateIndex, if any.
+func (c *Collection) Index(name string) (*Index, bool) {
+	return c.indexes.Get(name)
+}
+
+func entryID(document, key string) string {
+	return document + "\x00" + key
+}
+
+// rebuild recomputes idx from scratch by re-running its extractor over every Item currently in its Collection.
+func (idx *Index) rebuild() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	tree := iradix.New[[]indexRef]()
+	keys := map[string][]byte{}
+
+	for documentName, document := range idx.collection.documents.Data {
+		for key, raw := range document.data.Data {
+			item := Item{Key: key, Value: raw, ds: idx.collection.ds}
+
+			indexKey := idx.extractor(item)
+			if indexKey == nil {
+				continue
+			}
+
+			refs, _ := tree.Get(indexKey)
+			tree, _, _ = tree.Insert(indexKey, append(refs, indexRef{Document: documentName, Key: key}))
+			keys[entryID(documentName, key)] = indexKey
+		}
+	}
+
+	idx.tree = tree
+	idx.keys = keys
+}
+
+// update keeps idx in sync with a single Document key having just been set (removed == false, with item holding
+// its new value) or deleted (removed == true). Both the removal of any stale entry and the insertion of the new
+// one happen under idx's lock, so a concurrent Query never observes a half-applied update.
+func (idx *Index) update(documentName, key string, item Item, removed bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	id := entryID(documentName, key)
+
+	if oldKey, ok := idx.keys[id]; ok {
+		idx.removeRef(oldKey, documentName, key)
+		delete(idx.keys, id)
+	}
+
+	if removed {
+		return
+	}
+
+	newKey := idx.extractor(item)
+	if newKey == nil {
+		return
+	}
+
+	refs, _ := idx.tree.Get(newKey)
+	idx.tree, _, _ = idx.tree.Insert(newKey, append(refs, indexRef{Document: documentName, Key: key}))
+	idx.keys[id] = newKey
+}
+
+// removeRef must be called with idx.mu held.
+func (idx *Index) removeRef(indexKey []byte, documentName, key string) {
+	refs, ok := idx.tree.Get(indexKey)
+	if !ok {
+		return
+	}
+
+	filtered := make([]indexRef, 0, len(refs))
+	for _, ref := range refs {
+		if ref.Document == documentName && ref.Key == key {
+			continue
+		}
+		filtered = append(filtered, ref)
+	}
+
+	if len(filtered) == 0 {
+		idx.tree, _, _ = idx.tree.Delete(indexKey)
+	} else {
+		idx.tree, _, _ = idx.tree.Insert(indexKey, filtered)
+	}
+}
+
+// scan walks idx in sorted key order, optionally constrained to a prefix and/or an inclusive [lo, hi] range, and
+// resolves each matching entry back to its current Item. ctx is checked once per index key so a cancellation
+// aborts a scan over a large index instead of running it to completion.
+func (idx *Index) scan(ctx context.Context, prefix, lo, hi []byte, hasRange bool) ([]Item, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var items []Item
+
+	it := idx.tree.Root().Iterator()
+	switch {
+	case len(prefix) > 0:
+		it.SeekPrefix(prefix)
+	case hasRange:
+		it.SeekLowerBound(lo)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("datastore: query: index %q: %w", idx.name, err)
+		}
+
+		indexKey, refs, ok := it.Next()
+		if !ok {
+			break
+		}
+
+		if len(prefix) > 0 && !bytes.HasPrefix(indexKey, prefix) {
+			break
+		}
+		if hasRange && bytes.Compare(indexKey, hi) > 0 {
+			break
+		}
+		// The iterator was seeked to the prefix rather than lo when both are set, so lo must be enforced here.
+		if hasRange && bytes.Compare(indexKey, lo) < 0 {
+			continue
+		}
+
+		for _, ref := range refs {
+			document, ok := idx.collection.documents.Get(ref.Document)
+			if !ok {
+				continue
+			}
+			items = append(items, document.Get(ref.Key))
+		}
+	}
+
+	return items, nil
+}
+
+// Query builds a scan over a Collection's Items, either a full scan or, if Using is called, a scan of one of its
+// indexes.
+type Query struct {
+	collection *Collection
+	index      *Index
+
+	prefix   []byte
+	rangeLo  []byte
+	rangeHi  []byte
+	hasRange bool
+
+	where   func(Item) bool
+	orderBy func(a, b Item) bool
+	limit   int
+}
+
+// Query returns a new Query over c's Items. By default it does a full scan of every Document in c; call Using to
+// scan an index instead.
+func (c *Collection) Query() *Query {
+	return &Query{collection: c, limit: -1}
+}
+
+// Using restricts the Query to scanning idx rather than doing a full Collection scan. idx must belong to the same
+// Collection the Query was created from.
+func (q *Query) Using(idx *Index) *Query {
+	q.index = idx
+	return q
+}
+
+// Where filters Items, keeping only those pred returns true for.
+func (q *Query) Where(pred func(Item) bool) *Query {
+	q.where = pred
+	return q
+}
+
+// Range restricts an index scan to keys in the inclusive range [lo, hi]. It has no effect without Using.
+func (q *Query) Range(lo, hi []byte) *Query {
+	q.rangeLo = lo
+	q.rangeHi = hi
+	q.hasRange = true
+	return q
+}
+
+// Prefix restricts an index scan to keys starting with prefix. It has no effect without Using.
+func (q *Query) Prefix(prefix []byte) *Query {
+	q.prefix = prefix
+	return q
+}
+
+// Limit caps the number of Items Run returns. A negative limit (the default) means unlimited.
+func (q *Query) Limit(n int) *Query {
+	q.limit = n
+	return q
+}
+
+// OrderBy sorts the result with less before applying Limit.
+func (q *Query) OrderBy(less func(a, b Item) bool) *Query {
+	q.orderBy = less
+	return q
+}
+
+// Run executes the Query and returns the matching Items. It's equivalent to RunCtx with context.Background().
+func (q *Query) Run() ([]Item, error) {
+	return q.RunCtx(context.Background())
+}
+
+// RunCtx is the context-aware equivalent of Run. ctx is checked before the scan begins and once per result batch
+// while iterating an index; a cancellation aborts the scan and returns ctx.Err() wrapped instead of a partial or
+// stale result.
+func (q *Query) RunCtx(ctx context.Context) ([]Item, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("datastore: query: %w", err)
+	}
+
+	var items []Item
+
+	if q.index != nil {
+		if q.index.collection != q.collection {
+			return nil, fmt.Errorf("datastore: index %q does not belong to this collection", q.index.name)
+		}
+
+		var err error
+		items, err = q.index.scan(ctx, q.prefix, q.rangeLo, q.rangeHi, q.hasRange)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		for _, document := range q.collection.documents.Data {
+			for key, raw := range document.data.Data {
+				items = append(items, Item{Key: key, Value: raw, ds: q.collection.ds})
+			}
+		}
+	}
+
+	if q.where != nil {
+		filtered := items[:0]
+		for _, item := range items {
+			if q.where(item) {
+				filtered = append(filtered, item)
+			}
+		}
+		items = filtered
+	}
+
+	if q.orderBy != nil {
+		sort.Slice(items, func(i, j int) bool {
+			return q.orderBy(items[i], items[j])
+		})
+	}
+
+	if q.limit >= 0 && len(items) > q.limit {
+		items = items[:q.limit]
+	}
+
+	return items, nil
+}
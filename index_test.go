@@ -0,0 +1,148 @@
+package datastore
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func byValueExtractor(item Item) []byte {
+	_, payload, err := decodeItem(item.Value)
+	if err != nil {
+		return nil
+	}
+	return payload
+}
+
+func TestCreateIndexAndQuery(t *testing.T) {
+	dir := t.TempDir()
+
+	ds, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	RegisterExtractor("index-test-by-value", byValueExtractor)
+
+	collection := ds.Collection("people")
+	for name, age := range map[string]int{"alice": 30, "bob": 25, "carol": 40} {
+		if err := collection.Document(name).Set("age", age); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+
+	idx, err := collection.CreateIndex("by-age", "index-test-by-value")
+	if err != nil {
+		t.Fatalf("CreateIndex: %v", err)
+	}
+
+	items, err := collection.Query().Using(idx).Run()
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("got %d items, want 3", len(items))
+	}
+}
+
+func TestIndexRebuildsOnOpen(t *testing.T) {
+	dir := t.TempDir()
+
+	ds, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	RegisterExtractor("index-test-rebuild", byValueExtractor)
+
+	collection := ds.Collection("people")
+	if err := collection.Document("dave").Set("age", 50); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, err := collection.CreateIndex("by-age", "index-test-rebuild"); err != nil {
+		t.Fatalf("CreateIndex: %v", err)
+	}
+	if err := ds.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	idx, ok := reopened.Collection("people").Index("by-age")
+	if !ok {
+		t.Fatalf("index %q was not rebuilt on Open", "by-age")
+	}
+
+	items, err := reopened.Collection("people").Query().Using(idx).Run()
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want 1", len(items))
+	}
+}
+
+func TestOpenFailsWhenIndexExtractorNotRegistered(t *testing.T) {
+	dir := t.TempDir()
+
+	ds, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	if err := ds.Collection("people").Document("erin").Set("age", 22); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	// Record a manifest entry directly, bypassing CreateIndex's own registration check, to simulate the manifest
+	// naming an extractor this process (a stand-in for a fresh one in production) never registered.
+	if err := ds.persistIndex([]string{"people"}, "by-age", "index-test-never-registered"); err != nil {
+		t.Fatalf("persistIndex: %v", err)
+	}
+	if err := ds.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if _, err := Open(dir); err == nil {
+		t.Fatalf("expected Open to fail for an index whose extractor was never registered")
+	}
+}
+
+func TestQueryRunCtxCancelled(t *testing.T) {
+	dir := t.TempDir()
+
+	ds, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	collection := ds.Collection("people")
+	if err := collection.Document("frank").Set("age", 33); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	RegisterExtractor("index-test-ctx", byValueExtractor)
+	idx, err := collection.CreateIndex("by-age-ctx", "index-test-ctx")
+	if err != nil {
+		t.Fatalf("CreateIndex: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := collection.Query().Using(idx).RunCtx(ctx); err == nil {
+		t.Fatalf("expected RunCtx to return an error for an already-cancelled context")
+	}
+
+	// A deadline that's already passed behaves the same way.
+	ctx2, cancel2 := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel2()
+	time.Sleep(time.Millisecond)
+
+	if _, err := collection.Query().Using(idx).RunCtx(ctx2); err == nil {
+		t.Fatalf("expected RunCtx to return an error for an expired deadline")
+	}
+}
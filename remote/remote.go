@@ -0,0 +1,304 @@
+// Package remote is a thin HTTP client for a Datastore exposed with datastore.NewServer. Its Document satisfies
+// datastore.DocumentReadWriter; its Collection does not (see Collection's doc comment for why).
+package remote
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/eolso/datastore"
+)
+
+// Credentials authenticates requests made by a Datastore against a server configured with datastore.WithBasicAuth
+// or datastore.WithTokenAuth. The zero value sends no credentials at all.
+type Credentials struct {
+	Username string
+	Password string
+	Token    string
+}
+
+func (c Credentials) apply(r *http.Request) {
+	if c.Token != "" {
+		r.Header.Set("Authorization", "Bearer "+c.Token)
+		return
+	}
+
+	if c.Username != "" || c.Password != "" {
+		r.SetBasicAuth(c.Username, c.Password)
+	}
+}
+
+// Datastore is a client for a Datastore exposed remotely over HTTP by datastore.NewServer.
+type Datastore struct {
+	baseURL string
+	creds   Credentials
+	client  *http.Client
+}
+
+// Open returns a Datastore client that talks to baseURL, authenticating with creds. It does not perform any
+// request itself: it only returns an error for a baseURL that doesn't parse as a URL, and an unreachable server
+// only surfaces once a method is called.
+func Open(baseURL string, creds Credentials) (*Datastore, error) {
+	if _, err := url.Parse(baseURL); err != nil {
+		return nil, fmt.Errorf("datastore/remote: invalid base URL: %w", err)
+	}
+
+	return &Datastore{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		creds:   creds,
+		client:  http.DefaultClient,
+	}, nil
+}
+
+// Collection returns a client for the top level Collection named name. No request is made; the server creates the
+// Collection the first time a request names it.
+func (d *Datastore) Collection(name string) *Collection {
+	return &Collection{ds: d, path: []string{name}}
+}
+
+func (d *Datastore) do(method, path string, query url.Values, body []byte) (*http.Response, error) {
+	u := d.baseURL + "/" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequest(method, u, reader)
+	if err != nil {
+		return nil, fmt.Errorf("datastore/remote: %w", err)
+	}
+	d.creds.apply(req)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("datastore/remote: %w", err)
+	}
+
+	return resp, nil
+}
+
+// readNames decodes the gob-encoded []string body written by the server's writeNames helper.
+func readNames(resp *http.Response) ([]string, error) {
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("datastore/remote: %w", err)
+	}
+
+	var names []string
+	if err := datastore.GobCodec.Decode(b, &names); err != nil {
+		return nil, fmt.Errorf("datastore/remote: %w", err)
+	}
+
+	return names, nil
+}
+
+// Collection is a client for a single Collection on a remote Datastore. Its Get and GetAll return this package's
+// own Document, not datastore.CollectionReadWriter's *datastore.Document, so Collection does not itself satisfy
+// that interface: a *datastore.Document is tied to a local in-memory data map, and a Get here would
+// either have to fetch and materialize the whole thing up front (defeating the point of a thin client) or hand back
+// a value whose Set/Delete calls silently go nowhere. Document below has no such conflict and does satisfy
+// datastore.DocumentReadWriter.
+type Collection struct {
+	ds   *Datastore
+	path []string
+}
+
+func (c *Collection) pathString() string {
+	return strings.Join(c.path, "/")
+}
+
+// Collection returns a client for the Collection named name nested directly under c. No request is made; the server
+// creates the Collection the first time a request names it.
+func (c *Collection) Collection(name string) *Collection {
+	path := make([]string, len(c.path), len(c.path)+1)
+	copy(path, c.path)
+	path = append(path, name)
+
+	return &Collection{ds: c.ds, path: path}
+}
+
+// Document returns a client for the Document named name directly in c. Unlike the root package's
+// Collection.Document, no request is made until the returned Document is used, so whether name exists on the
+// server isn't known yet.
+func (c *Collection) Document(name string) *Document {
+	return &Document{collection: c, name: name}
+}
+
+// Get returns the Document named key in c, and whether it exists on the server.
+func (c *Collection) Get(key string) (*Document, bool) {
+	resp, err := c.ds.do(http.MethodGet, c.pathString()+"/"+key, nil, nil)
+	if err != nil {
+		return nil, false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, false
+	}
+
+	return &Document{collection: c, name: key}, resp.StatusCode == http.StatusOK
+}
+
+// GetAll returns a Document for every name the server reports directly under c.
+func (c *Collection) GetAll() []*Document {
+	query := url.Values{"documents": {"1"}}
+	resp, err := c.ds.do(http.MethodGet, c.pathString(), query, nil)
+	if err != nil {
+		return nil
+	}
+
+	names, err := readNames(resp)
+	if err != nil {
+		return nil
+	}
+
+	documents := make([]*Document, 0, len(names))
+	for _, name := range names {
+		documents = append(documents, c.Document(name))
+	}
+
+	return documents
+}
+
+// Set writes every key currently held by document into c's copy of it on the server, creating it if it doesn't
+// already exist.
+func (c *Collection) Set(key string, document *datastore.Document) error {
+	for _, item := range document.GetAll() {
+		if err := c.Document(key).setRaw(item.Key, item.Value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes the Document named key from c on the server.
+func (c *Collection) Delete(key string) error {
+	resp, err := c.ds.do(http.MethodDelete, c.pathString()+"/"+key, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
+// DeleteCollection removes the Collection named key nested directly under c on the server.
+func (c *Collection) DeleteCollection(key string) {
+	query := url.Values{"collection": {"1"}}
+	resp, err := c.ds.do(http.MethodDelete, c.pathString()+"/"+key, query, nil)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+}
+
+// Document is a client for a single Document on a remote Datastore. It satisfies datastore.DocumentReadWriter.
+type Document struct {
+	collection *Collection
+	name       string
+}
+
+func (d *Document) pathString() string {
+	return d.collection.pathString() + "/" + d.name
+}
+
+// Get reads key from d and returns it as a datastore.Item, the same type a local Document.Get returns. Item.Reader
+// is not usable on the result, since the bytes it holds were fetched over HTTP rather than read from a Datastore's
+// blobs directory.
+func (d *Document) Get(key string) datastore.Item {
+	query := url.Values{"key": {key}}
+	resp, err := d.collection.ds.do(http.MethodGet, d.pathString(), query, nil)
+	if err != nil {
+		return datastore.Item{}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return datastore.Item{}
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return datastore.Item{}
+	}
+
+	return datastore.Item{Key: key, Value: b}
+}
+
+// GetAll returns every key currently held by d on the server.
+func (d *Document) GetAll() []datastore.Item {
+	resp, err := d.collection.ds.do(http.MethodGet, d.pathString(), nil, nil)
+	if err != nil {
+		return nil
+	}
+
+	names, err := readNames(resp)
+	if err != nil {
+		return nil
+	}
+
+	items := make([]datastore.Item, 0, len(names))
+	for _, name := range names {
+		items = append(items, d.Get(name))
+	}
+
+	return items
+}
+
+// Set encodes value with GobCodec and writes it to key on d. Unlike the root package's Document.Set, a remote
+// Document has no per-Document codec override, since the client can't reach into the server's Datastore to ask
+// what its default is; use SetWithCodec to pick a different one.
+func (d *Document) Set(key string, value interface{}) error {
+	return d.SetWithCodec(key, value, datastore.GobCodec)
+}
+
+// SetWithCodec is Set with an explicit Codec instead of GobCodec.
+func (d *Document) SetWithCodec(key string, value interface{}, codec datastore.Codec) error {
+	b, err := datastore.EncodeValue(codec, value)
+	if err != nil {
+		return fmt.Errorf("datastore/remote: %w", err)
+	}
+
+	return d.setRaw(key, b)
+}
+
+func (d *Document) setRaw(key string, b []byte) error {
+	query := url.Values{"key": {key}}
+	resp, err := d.collection.ds.do(http.MethodPut, d.pathString(), query, b)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("datastore/remote: server returned %s", resp.Status)
+	}
+
+	return nil
+}
+
+// Delete removes key from d on the server.
+func (d *Document) Delete(key string) error {
+	query := url.Values{"key": {key}}
+	resp, err := d.collection.ds.do(http.MethodDelete, d.pathString(), query, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
+// Document does satisfy datastore.DocumentReadWriter, unlike Collection (see its doc comment).
+var _ datastore.DocumentReadWriter = (*Document)(nil)
@@ -0,0 +1,115 @@
+package remote_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eolso/datastore"
+	"github.com/eolso/datastore/remote"
+)
+
+func TestClientServerRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	ds, err := datastore.Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	server := httptest.NewServer(datastore.NewServer(ds))
+	defer server.Close()
+
+	client, err := remote.Open(server.URL, remote.Credentials{})
+	if err != nil {
+		t.Fatalf("remote.Open: %v", err)
+	}
+
+	doc := client.Collection("people").Document("alice")
+	if err := doc.Set("age", 30); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	var age int
+	if err := doc.Get("age").Decode(&age); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if age != 30 {
+		t.Fatalf("age = %d, want 30", age)
+	}
+
+	// The write must be visible through the local Datastore too, since both sides share the same underlying data.
+	var localAge int
+	if err := ds.Collection("people").Document("alice").Get("age").Decode(&localAge); err != nil {
+		t.Fatalf("local Decode: %v", err)
+	}
+	if localAge != 30 {
+		t.Fatalf("local age = %d, want 30", localAge)
+	}
+
+	if err := doc.Delete("age"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	item := doc.Get("age")
+	if len(item.Value) != 0 {
+		t.Fatalf("key %q should have been deleted, got %q", "age", item.Value)
+	}
+}
+
+func TestClientCollectionDelete(t *testing.T) {
+	dir := t.TempDir()
+
+	ds, err := datastore.Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	server := httptest.NewServer(datastore.NewServer(ds))
+	defer server.Close()
+
+	client, err := remote.Open(server.URL, remote.Credentials{})
+	if err != nil {
+		t.Fatalf("remote.Open: %v", err)
+	}
+
+	collection := client.Collection("people")
+	if err := collection.Document("bob").Set("age", 25); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	if err := collection.Delete("bob"); err != nil {
+		t.Fatalf("Collection.Delete: %v", err)
+	}
+
+	if _, ok := ds.Collection("people").Get("bob"); ok {
+		t.Fatalf("document %q should have been deleted", "bob")
+	}
+}
+
+func TestClientBasicAuth(t *testing.T) {
+	dir := t.TempDir()
+
+	ds, err := datastore.Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	server := httptest.NewServer(datastore.NewServer(ds, datastore.WithBasicAuth("user", "pass")))
+	defer server.Close()
+
+	unauthenticated, err := remote.Open(server.URL, remote.Credentials{})
+	if err != nil {
+		t.Fatalf("remote.Open: %v", err)
+	}
+	if err := unauthenticated.Collection("people").Document("alice").Set("age", 30); err == nil {
+		t.Fatalf("expected Set to fail without credentials")
+	}
+
+	authenticated, err := remote.Open(server.URL, remote.Credentials{Username: "user", Password: "pass"})
+	if err != nil {
+		t.Fatalf("remote.Open: %v", err)
+	}
+	if err := authenticated.Collection("people").Document("alice").Set("age", 30); err != nil {
+		t.Fatalf("Set with credentials: %v", err)
+	}
+}
@@ -0,0 +1,235 @@
+package datastore
+
+import (
+	"io"
+	"net/http"
+	"strings"
+)
+
+// collectionHolder is satisfied by both *Datastore and *Collection, letting the server walk a request path's
+// collection chain uniformly regardless of whether it starts at the Datastore root or partway into it.
+type collectionHolder interface {
+	Collection(name string) *Collection
+	DeleteCollection(name string)
+	Collections() []string
+}
+
+// ServerOption configures a Server returned by NewServer.
+type ServerOption func(*server)
+
+// WithBasicAuth restricts every request to the server to ones presenting username and password via HTTP Basic
+// Auth.
+func WithBasicAuth(username, password string) ServerOption {
+	return func(s *server) {
+		s.authenticate = func(r *http.Request) bool {
+			u, p, ok := r.BasicAuth()
+			return ok && u == username && p == password
+		}
+	}
+}
+
+// WithTokenAuth restricts every request to the server to ones presenting token in an
+// "Authorization: Bearer <token>" header.
+func WithTokenAuth(token string) ServerOption {
+	return func(s *server) {
+		s.authenticate = func(r *http.Request) bool {
+			return r.Header.Get("Authorization") == "Bearer "+token
+		}
+	}
+}
+
+// server implements http.Handler for a single Datastore. See NewServer.
+type server struct {
+	ds           *Datastore
+	authenticate func(*http.Request) bool
+}
+
+// NewServer returns an http.Handler exposing ds to a remote.Datastore client over HTTP.
+//
+// Every request path is a slash-separated chain of Collection names, optionally ending in a Document name, with
+// the method and query parameters selecting the operation:
+//
+//	GET    /a/b?collection=1       list the Collections nested directly under a/b
+//	POST   /a/b?collection=1       create a/b (and any missing parent Collections)
+//	DELETE /a/b?collection=1       remove the Collection a/b from its parent
+//	GET    /a/b?documents=1        list the Documents directly in Collection a/b
+//	GET    /a/b/doc                list the keys held by Document doc in Collection a/b
+//	DELETE /a/b/doc                remove Document doc from Collection a/b
+//	GET    /a/b/doc?key=k          read key k from Document doc
+//	PUT    /a/b/doc?key=k          write the request body as key k on Document doc
+//	DELETE /a/b/doc?key=k          delete key k from Document doc
+//
+// Values are passed over the wire exactly as Document stores them: already encoded by whatever Codec produced
+// them, header and all, so the client can Item.Decode them without the server needing to know their Go type.
+func NewServer(ds *Datastore, opts ...ServerOption) http.Handler {
+	s := &server{ds: ds}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handle)
+
+	return mux
+}
+
+func (s *server) handle(w http.ResponseWriter, r *http.Request) {
+	if s.authenticate != nil && !s.authenticate(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(segments) == 0 || segments[0] == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.URL.Query().Has("collection") {
+		s.handleCollection(w, r, segments)
+		return
+	}
+
+	if r.URL.Query().Has("documents") {
+		s.handleListDocuments(w, r, segments)
+		return
+	}
+
+	if len(segments) < 2 {
+		http.Error(w, "datastore: a document path needs at least one collection and a document name", http.StatusBadRequest)
+		return
+	}
+
+	s.handleDocument(w, r, segments)
+}
+
+// resolveCollectionChain walks segments as a chain of nested Collection names starting at the Datastore root,
+// creating any that don't already exist. target is the Collection the last segment names, and parent is that same
+// Collection as a collectionHolder; for empty segments, parent is the Datastore itself and target is nil.
+func (s *server) resolveCollectionChain(segments []string) (parent collectionHolder, target *Collection) {
+	parent = s.ds
+
+	for _, name := range segments {
+		target = parent.Collection(name)
+		parent = target
+	}
+
+	return parent, target
+}
+
+func (s *server) handleCollection(w http.ResponseWriter, r *http.Request, segments []string) {
+	parentSegments, name := segments[:len(segments)-1], segments[len(segments)-1]
+	parent, _ := s.resolveCollectionChain(parentSegments)
+
+	switch r.Method {
+	case http.MethodPost:
+		parent.Collection(name)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		parent.DeleteCollection(name)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodGet:
+		collection := parent.Collection(name)
+		writeNames(w, collection.Collections())
+	default:
+		http.Error(w, "datastore: unsupported method for a collection path", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *server) handleListDocuments(w http.ResponseWriter, r *http.Request, segments []string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "datastore: unsupported method for a collection path", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, collection := s.resolveCollectionChain(segments)
+	documents := collection.GetAll()
+	names := make([]string, 0, len(documents))
+	for _, document := range documents {
+		names = append(names, document.Name)
+	}
+
+	writeNames(w, names)
+}
+
+func (s *server) handleDocument(w http.ResponseWriter, r *http.Request, segments []string) {
+	_, collection := s.resolveCollectionChain(segments[:len(segments)-1])
+	documentName := segments[len(segments)-1]
+
+	key := r.URL.Query().Get("key")
+
+	switch r.Method {
+	case http.MethodGet:
+		document, ok := collection.Get(documentName)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		if key == "" {
+			items := document.GetAll()
+			names := make([]string, 0, len(items))
+			for _, item := range items {
+				names = append(names, item.Key)
+			}
+			writeNames(w, names)
+			return
+		}
+
+		item := document.Get(key)
+		if len(item.Value) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(item.Value)
+	case http.MethodPut:
+		if key == "" {
+			http.Error(w, "datastore: PUT requires a key", http.StatusBadRequest)
+			return
+		}
+
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		document := collection.Document(documentName)
+		if err := document.setRaw(key, b); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		if key == "" {
+			if err := collection.Delete(documentName); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if document, ok := collection.Get(documentName); ok {
+			if err := document.Delete(key); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "datastore: unsupported method for a document path", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeNames(w http.ResponseWriter, names []string) {
+	payload, err := GobCodec.Encode(names)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = w.Write(payload)
+}
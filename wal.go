@@ -0,0 +1,183 @@
+package datastore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// SyncPolicy controls when a WAL's writes are flushed to stable storage with fsync.
+type SyncPolicy int
+
+const (
+	// SyncAlways fsyncs after every WAL append. Safest, slowest.
+	SyncAlways SyncPolicy = iota
+	// SyncInterval fsyncs at most once per configured interval. See WithSyncInterval.
+	SyncInterval
+	// SyncNever never explicitly fsyncs, relying on the OS to flush eventually.
+	SyncNever
+)
+
+type walOp byte
+
+const (
+	walOpSet walOp = iota + 1
+	walOpDelete
+)
+
+// walRecord is a single journaled mutation. CollectionPath is the chain of Collection names from the Datastore root
+// down to (but not including) Document.
+type walRecord struct {
+	Op             walOp
+	CollectionPath []string
+	Document       string
+	Key            string
+	Value          []byte
+}
+
+// wal is an append-only journal of mutations made to a Datastore since its last Checkpoint.
+type wal struct {
+	mu       sync.Mutex
+	file     *os.File
+	policy   SyncPolicy
+	interval time.Duration
+	lastSync time.Time
+}
+
+// openWAL opens (creating if necessary) the WAL file at path in append mode.
+func openWAL(path string, policy SyncPolicy, interval time.Duration) (*wal, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &wal{
+		file:     file,
+		policy:   policy,
+		interval: interval,
+	}, nil
+}
+
+// replay reads every record currently in the WAL and calls apply for each one, in order.
+func (w *wal) replay(apply func(walRecord) error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader := io.Reader(w.file)
+	for {
+		// Recorded before the length prefix is read so a torn record - the tail left behind by a crash mid-append -
+		// can be truncated away rather than treated as a fatal replay error.
+		pos, err := w.file.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+
+		var length uint32
+		if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
+			if err == io.EOF {
+				break
+			}
+			if err == io.ErrUnexpectedEOF {
+				return w.truncateTorn(pos)
+			}
+			return fmt.Errorf("datastore: wal replay: %w", err)
+		}
+
+		buf := make([]byte, length)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			if err == io.ErrUnexpectedEOF || err == io.EOF {
+				return w.truncateTorn(pos)
+			}
+			return fmt.Errorf("datastore: wal replay: %w", err)
+		}
+
+		var record walRecord
+		if err := gob.NewDecoder(bytes.NewReader(buf)).Decode(&record); err != nil {
+			return fmt.Errorf("datastore: wal replay: %w", err)
+		}
+
+		if err := apply(record); err != nil {
+			return err
+		}
+	}
+
+	// Leave the file positioned at the end so subsequent appends are tailed on.
+	_, err := w.file.Seek(0, io.SeekEnd)
+	return err
+}
+
+// truncateTorn discards a torn record starting at pos - the remnant of a write that never completed, left behind
+// by a crash partway through append - so a subsequent append tails cleanly onto the last complete record instead
+// of onto a corrupt fragment.
+func (w *wal) truncateTorn(pos int64) error {
+	if err := w.file.Truncate(pos); err != nil {
+		return fmt.Errorf("datastore: wal replay: %w", err)
+	}
+
+	_, err := w.file.Seek(0, io.SeekEnd)
+	return err
+}
+
+// append writes record to the WAL as a length-prefixed gob record, applying the configured SyncPolicy.
+func (w *wal) append(record walRecord) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(record); err != nil {
+		return fmt.Errorf("datastore: wal append: %w", err)
+	}
+
+	if err := binary.Write(w.file, binary.BigEndian, uint32(buf.Len())); err != nil {
+		return fmt.Errorf("datastore: wal append: %w", err)
+	}
+	if _, err := w.file.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("datastore: wal append: %w", err)
+	}
+
+	switch w.policy {
+	case SyncAlways:
+		return w.file.Sync()
+	case SyncInterval:
+		if time.Since(w.lastSync) >= w.interval {
+			w.lastSync = time.Now()
+			return w.file.Sync()
+		}
+	case SyncNever:
+	}
+
+	return nil
+}
+
+// reset discards all records by reopening the WAL as an empty file at its original path. Called after a successful
+// Checkpoint folds them into the snapshot on disk; the file is reopened rather than truncated in place because the
+// Checkpoint swaps a new directory in at the Datastore's path, unlinking the file the WAL had open.
+func (w *wal) reset() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	file, err := os.OpenFile(w.file.Name(), os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	old := w.file
+	w.file = file
+	return old.Close()
+}
+
+func (w *wal) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.file.Close()
+}